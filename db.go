@@ -1,18 +1,26 @@
-// Simple way to use a MySQL/MariaDB database
+// Simple way to use a MySQL/MariaDB, PostgreSQL or SQLite database
 package db
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"errors"
-	_ "github.com/go-sql-driver/mysql"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"log"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Host struct {
-	db     *sql.DB
-	dbname string
+	db      *sql.DB
+	dbname  string
+	dialect dialect
 }
 
 // Common for each of the db datastructures used here
@@ -50,11 +58,40 @@ func TestConnection() (err error) {
 }
 
 // Test if a given database server is up and running.
-// connectionString may be on the form "username:password@host:port/database".
+// connectionString may be on the form "username:password@host:port/database",
+// or a "mysql://", "postgres://" or "sqlite://" URL.
 func TestConnectionHost(connectionString string) (err error) {
-	newConnectionString, _ := rebuildConnectionString(connectionString)
+	driverName, dsn, _ := dsnFor(connectionString)
 	// Connect to the given host:port
-	db, err := sql.Open("mysql", newConnectionString)
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	err = db.Ping()
+	if Verbose {
+		if err != nil {
+			log.Println("Ping: failed")
+		} else {
+			log.Println("Ping: ok")
+		}
+	}
+	return err
+}
+
+// Test if a given MySQL/MariaDB server is up and running, over TLS.
+// connectionString may be on the form "username:password@host:port/database",
+// or a "mysql://" URL.
+func TestConnectionHostTLS(connectionString string, tlsConfig *TLSConfig) (err error) {
+	dsn, _ := rebuildMySQLConnectionString(strings.TrimPrefix(connectionString, "mysql://"))
+	dsn, err = dsnWithTLS(dsn, tlsConfig)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
 	defer db.Close()
 	err = db.Ping()
 	if Verbose {
@@ -69,31 +106,129 @@ func TestConnectionHost(connectionString string) (err error) {
 
 /* --- Host functions --- */
 
+// TLSConfig holds the parameters needed to connect to a MySQL/MariaDB
+// server over TLS with a custom certificate authority, mirroring what
+// Vault's MySQL physical backend supports. CACert, ClientCert and ClientKey
+// are all PEM-encoded. ClientCert/ClientKey are only needed for mutual TLS.
+type TLSConfig struct {
+	CACert             []byte
+	ClientCert         []byte
+	ClientKey          []byte
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// dsnWithTLS registers tlsConfig under a name derived from dsn and returns
+// dsn rewritten to reference it via "?tls=<name>" (go-sql-driver/mysql picks
+// up registered TLS configs by name this way).
+func dsnWithTLS(dsn string, tlsConfig *TLSConfig) (string, error) {
+	var pool *x509.CertPool
+	if len(tlsConfig.CACert) > 0 {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(tlsConfig.CACert) {
+			return "", errors.New("could not parse CA certificate")
+		}
+	}
+	conf := &tls.Config{
+		RootCAs:            pool,
+		ServerName:         tlsConfig.ServerName,
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+	}
+	if len(tlsConfig.ClientCert) > 0 || len(tlsConfig.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(tlsConfig.ClientCert, tlsConfig.ClientKey)
+		if err != nil {
+			return "", err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	// Derive a name that is unique per DSN, so that NewHostTLS can be called
+	// several times (for several servers) without the names colliding.
+	name := fmt.Sprintf("simplegres-%x", sha256.Sum256([]byte(dsn)))
+	if err := mysql.RegisterTLSConfig(name, conf); err != nil {
+		return "", err
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&tls=" + name, nil
+	}
+	return dsn + "?tls=" + name, nil
+}
+
+// dsnFor figures out which driver and DSN to use for a given connection
+// string. connectionString is either a bare MySQL-style
+// "username:password@host:port/database" (the historical default), or a
+// "mysql://", "postgres://"/"postgresql://" or "sqlite://" URL.
+func dsnFor(connectionString string) (driverName, dsn, dbname string) {
+	switch {
+	case strings.HasPrefix(connectionString, "postgres://"), strings.HasPrefix(connectionString, "postgresql://"):
+		dsn, dbname = rebuildConnectionString(connectionString)
+		return "postgres", dsn, dbname
+	case strings.HasPrefix(connectionString, "sqlite://"):
+		dsn = strings.TrimPrefix(connectionString, "sqlite://")
+		return "sqlite3", dsn, dsn
+	default:
+		dsn, dbname = rebuildMySQLConnectionString(connectionString)
+		return "mysql", dsn, dbname
+	}
+}
+
 // Create a new database connection.
-// connectionString may be on the form "username:password@host:port/database".
-func NewHost(connectionString string) *Host {
+// connectionString may be on the form "username:password@host:port/database",
+// or a "mysql://", "postgres://" or "sqlite://" URL. Use NewHostWithDialect
+// instead if the driver and DSN are already known.
+func NewHost(connectionString string) (*Host, error) {
+	driverName, dsn, dbname := dsnFor(connectionString)
+	d, err := dialectFor(driverName)
+	if err != nil {
+		return nil, err
+	}
+	return newHost(driverName, d, dsn, dbname)
+}
+
+// Create a new MySQL/MariaDB database connection over TLS, using a
+// CA bundle (and optionally a client certificate/key) instead of the
+// server's default trust store.
+// connectionString may be on the form "username:password@host:port/database",
+// or a "mysql://" URL.
+func NewHostTLS(connectionString string, tlsConfig *TLSConfig) (*Host, error) {
+	dsn, dbname := rebuildMySQLConnectionString(strings.TrimPrefix(connectionString, "mysql://"))
+	dsn, err := dsnWithTLS(dsn, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newHost("mysql", mysqlDialect{}, dsn, dbname)
+}
 
-	newConnectionString, dbname := rebuildConnectionString(connectionString)
+// Create a new database connection for a known database/sql driver name
+// ("mysql", "postgres" or "sqlite3") and a DSN already in that driver's form.
+func NewHostWithDialect(driverName, dsn string) (*Host, error) {
+	d, err := dialectFor(driverName)
+	if err != nil {
+		return nil, err
+	}
+	return newHost(driverName, d, dsn, "")
+}
 
-	db, err := sql.Open("mysql", newConnectionString)
+func newHost(driverName string, d dialect, dsn, dbname string) (*Host, error) {
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
-		log.Fatalln("Could not connect to " + newConnectionString + "!")
+		return nil, errors.New("could not connect to " + dsn + ": " + err.Error())
 	}
-	host := &Host{db, dbname}
+	host := &Host{db, dbname, d}
+	applyPoolDefaultsFromEnv(host)
 	if err := db.Ping(); err != nil {
-		log.Fatalln("Database does not reply to ping: " + err.Error())
+		return nil, errors.New("database does not reply to ping: " + err.Error())
 	}
 	if err := host.createDatabase(); err != nil {
-		log.Fatalln("Could not create database " + host.dbname + ": " + err.Error())
+		return nil, errors.New("could not create database " + host.dbname + ": " + err.Error())
 	}
 	if err := host.useDatabase(); err != nil {
-		panic("Could not use database " + host.dbname + ": " + err.Error())
+		return nil, errors.New("could not use database " + host.dbname + ": " + err.Error())
 	}
-	return host
+	return host, nil
 }
 
 // The default database connection
-func New() *Host {
+func New() (*Host, error) {
 	connectionString := defaultDatabaseServer + defaultDatabaseName
 	if !strings.HasSuffix(defaultDatabaseServer, "/") {
 		connectionString = defaultDatabaseServer + "/" + defaultDatabaseName
@@ -113,9 +248,18 @@ func (host *Host) SelectDatabase(dbname string) error {
 	return nil
 }
 
-// Will create the database if it does not already exist.
+// Will create the database if it does not already exist. Dialects that have
+// no separate "database" concept to create up front (SQLite), or where the
+// dbname is not known yet (NewHostWithDialect without a dbname), are no-ops.
 func (host *Host) createDatabase() error {
-	if _, err := host.db.Exec("CREATE DATABASE IF NOT EXISTS " + host.dbname + " CHARACTER SET = utf8"); err != nil {
+	if host.dbname == "" {
+		return nil
+	}
+	stmt := host.dialect.createDatabase(host.dbname)
+	if stmt == "" {
+		return nil
+	}
+	if _, err := host.db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "already exists") {
 		return err
 	}
 	if Verbose {
@@ -124,9 +268,17 @@ func (host *Host) createDatabase() error {
 	return nil
 }
 
-// Use the host.dbname database.
+// Use the host.dbname database. Dialects that select the database via the
+// DSN instead of a separate statement (PostgreSQL, SQLite) are no-ops.
 func (host *Host) useDatabase() error {
-	if _, err := host.db.Exec("USE " + host.dbname); err != nil {
+	if host.dbname == "" {
+		return nil
+	}
+	stmt := host.dialect.useDatabase(host.dbname)
+	if stmt == "" {
+		return nil
+	}
+	if _, err := host.db.Exec(stmt); err != nil {
 		return err
 	}
 	if Verbose {
@@ -140,13 +292,75 @@ func (host *Host) Close() {
 	host.db.Close()
 }
 
+// Environment variables used by applyPoolDefaultsFromEnv to configure the
+// connection pool of a freshly opened Host, so long-running services don't
+// have to call SetPool themselves to avoid leaking connections against
+// limits such as MariaDB's wait_timeout.
+const (
+	envMaxOpen      = "SIMPLEGRES_MAX_OPEN"
+	envMaxIdle      = "SIMPLEGRES_MAX_IDLE"
+	envConnLifetime = "SIMPLEGRES_CONN_LIFETIME"
+)
+
+// applyPoolDefaultsFromEnv applies pool settings from the SIMPLEGRES_MAX_OPEN,
+// SIMPLEGRES_MAX_IDLE and SIMPLEGRES_CONN_LIFETIME environment variables, if set.
+// Invalid values are ignored, leaving database/sql's own defaults in place.
+func applyPoolDefaultsFromEnv(host *Host) {
+	if v := os.Getenv(envMaxOpen); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			host.db.SetMaxOpenConns(n)
+		}
+	}
+	if v := os.Getenv(envMaxIdle); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			host.db.SetMaxIdleConns(n)
+		}
+	}
+	if v := os.Getenv(envConnLifetime); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			host.db.SetConnMaxLifetime(d)
+		}
+	}
+}
+
+// SetPool configures the connection pool: the maximum number of open and
+// idle connections, and how long a connection may be reused for (lifetime)
+// or sit idle (idle time) before being closed. A zero duration means no limit.
+func (host *Host) SetPool(maxOpen, maxIdle int, connMaxLifetime, connMaxIdleTime time.Duration) {
+	host.db.SetMaxOpenConns(maxOpen)
+	host.db.SetMaxIdleConns(maxIdle)
+	host.db.SetConnMaxLifetime(connMaxLifetime)
+	host.db.SetConnMaxIdleTime(connMaxIdleTime)
+}
+
+// Stats returns connection pool statistics for the underlying *sql.DB.
+func (host *Host) Stats() sql.DBStats {
+	return host.db.Stats()
+}
+
+// Ping checks that the database connection is still alive, honoring ctx's deadline/cancellation.
+func (host *Host) Ping(ctx context.Context) error {
+	return host.db.PingContext(ctx)
+}
+
+// q quotes a table or column identifier for this host's dialect.
+func (host *Host) q(ident string) string {
+	return host.dialect.quote(ident)
+}
+
+// ph returns the n'th (1-indexed) placeholder for this host's dialect.
+func (host *Host) ph(n int) string {
+	return host.dialect.placeholder(n)
+}
+
 /* --- List functions --- */
 
 // Create a new list. Lists are ordered.
 func NewList(host *Host, name string) *List {
 	l := &List{host, name}
 	// list is the name of the column
-	if _, err := l.host.db.Exec("CREATE TABLE IF NOT EXISTS " + name + " (id INT PRIMARY KEY AUTO_INCREMENT, " + listColName + " VARCHAR(" + strconv.Itoa(defaultStringLength) + "))"); err != nil {
+	stmt := "CREATE TABLE IF NOT EXISTS " + host.q(name) + " (id " + host.dialect.autoIncrement() + ", " + host.q(listColName) + " VARCHAR(" + strconv.Itoa(defaultStringLength) + "))"
+	if _, err := l.host.db.Exec(stmt); err != nil {
 		// This is more likely to happen at the start of the program,
 		// hence the panic.
 		panic("Could not create table " + name + ": " + err.Error())
@@ -159,16 +373,26 @@ func NewList(host *Host, name string) *List {
 
 // Add an element to the list
 func (rl *List) Add(value string) error {
+	return rl.AddContext(context.Background(), value)
+}
+
+// AddContext is Add, with a context.
+func (rl *List) AddContext(ctx context.Context, value string) error {
 	// list is the name of the column
-	_, err := rl.host.db.Exec("INSERT INTO "+rl.table+" ("+listColName+") VALUES (?)", value)
+	_, err := rl.host.db.ExecContext(ctx, "INSERT INTO "+rl.host.q(rl.table)+" ("+rl.host.q(listColName)+") VALUES ("+rl.host.ph(1)+")", value)
 	return err
 }
 
 // Get all elements of a list
 func (rl *List) GetAll() ([]string, error) {
-	rows, err := rl.host.db.Query("SELECT " + listColName + " FROM " + rl.table + " ORDER BY id")
+	return rl.GetAllContext(context.Background())
+}
+
+// GetAllContext is GetAll, with a context.
+func (rl *List) GetAllContext(ctx context.Context) ([]string, error) {
+	rows, err := rl.host.db.QueryContext(ctx, "SELECT "+rl.host.q(listColName)+" FROM "+rl.host.q(rl.table)+" ORDER BY id")
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	defer rows.Close()
 	var (
@@ -176,46 +400,55 @@ func (rl *List) GetAll() ([]string, error) {
 		value  string
 	)
 	for rows.Next() {
-		err = rows.Scan(&value)
-		values = append(values, value)
-		if err != nil {
-			panic(err.Error())
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
 		}
+		values = append(values, value)
 	}
 	if err := rows.Err(); err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	return values, nil
 }
 
 // Get the last element of a list
 func (rl *List) GetLast() (string, error) {
+	return rl.GetLastContext(context.Background())
+}
+
+// GetLastContext is GetLast, with a context.
+func (rl *List) GetLastContext(ctx context.Context) (string, error) {
 	// Fetches the item with the largest id.
 	// Faster than "ORDER BY id DESC limit 1" for large tables.
-	rows, err := rl.host.db.Query("SELECT " + listColName + " FROM " + rl.table + " WHERE id = (SELECT MAX(id) FROM " + rl.table + ")")
+	table := rl.host.q(rl.table)
+	rows, err := rl.host.db.QueryContext(ctx, "SELECT "+rl.host.q(listColName)+" FROM "+table+" WHERE id = (SELECT MAX(id) FROM "+table+")")
 	if err != nil {
-		panic(err.Error())
+		return "", err
 	}
 	defer rows.Close()
 	var value string
 	// Get the value. Will only loop once.
 	for rows.Next() {
-		err = rows.Scan(&value)
-		if err != nil {
-			panic(err.Error())
+		if err := rows.Scan(&value); err != nil {
+			return "", err
 		}
 	}
 	if err := rows.Err(); err != nil {
-		panic(err.Error())
+		return "", err
 	}
 	return value, nil
 }
 
 // Get the last N elements of a list
 func (rl *List) GetLastN(n int) ([]string, error) {
-	rows, err := rl.host.db.Query("SELECT " + listColName + " FROM (SELECT * FROM " + rl.table + " ORDER BY id DESC limit " + strconv.Itoa(n) + ")sub ORDER BY id ASC")
+	return rl.GetLastNContext(context.Background(), n)
+}
+
+// GetLastNContext is GetLastN, with a context.
+func (rl *List) GetLastNContext(ctx context.Context, n int) ([]string, error) {
+	rows, err := rl.host.db.QueryContext(ctx, "SELECT "+rl.host.q(listColName)+" FROM (SELECT * FROM "+rl.host.q(rl.table)+" ORDER BY id DESC limit "+strconv.Itoa(n)+")sub ORDER BY id ASC")
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	defer rows.Close()
 	var (
@@ -223,14 +456,13 @@ func (rl *List) GetLastN(n int) ([]string, error) {
 		value  string
 	)
 	for rows.Next() {
-		err = rows.Scan(&value)
-		values = append(values, value)
-		if err != nil {
-			panic(err.Error())
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
 		}
+		values = append(values, value)
 	}
 	if err := rows.Err(); err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	if len(values) < n {
 		return []string{}, errors.New("Too few elements in table at GetLastN")
@@ -240,15 +472,25 @@ func (rl *List) GetLastN(n int) ([]string, error) {
 
 // Remove this list
 func (rl *List) Remove() error {
+	return rl.RemoveContext(context.Background())
+}
+
+// RemoveContext is Remove, with a context.
+func (rl *List) RemoveContext(ctx context.Context) error {
 	// Remove the table
-	_, err := rl.host.db.Exec("DROP TABLE " + rl.table)
+	_, err := rl.host.db.ExecContext(ctx, "DROP TABLE "+rl.host.q(rl.table))
 	return err
 }
 
 // Clear the list contents
 func (rl *List) Clear() error {
+	return rl.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, with a context.
+func (rl *List) ClearContext(ctx context.Context) error {
 	// Clear the table
-	_, err := rl.host.db.Exec("TRUNCATE TABLE " + rl.table)
+	_, err := rl.host.db.ExecContext(ctx, rl.host.dialect.truncate(rl.table))
 	return err
 }
 
@@ -258,7 +500,8 @@ func (rl *List) Clear() error {
 func NewSet(host *Host, name string) *Set {
 	s := &Set{host, name}
 	// list is the name of the column
-	if _, err := s.host.db.Exec("CREATE TABLE IF NOT EXISTS " + name + " (" + setColName + " VARCHAR(" + strconv.Itoa(defaultStringLength) + "))"); err != nil {
+	stmt := "CREATE TABLE IF NOT EXISTS " + host.q(name) + " (" + host.q(setColName) + " VARCHAR(" + strconv.Itoa(defaultStringLength) + "))"
+	if _, err := s.host.db.Exec(stmt); err != nil {
 		// This is more likely to happen at the start of the program, hence the panic.
 		panic("Could not create table " + name + ": " + err.Error())
 	}
@@ -270,46 +513,61 @@ func NewSet(host *Host, name string) *Set {
 
 // Add an element to the set
 func (s *Set) Add(value string) error {
+	return s.AddContext(context.Background(), value)
+}
+
+// AddContext is Add, with a context.
+func (s *Set) AddContext(ctx context.Context, value string) error {
 	// Check if the value is not already there before adding
-	has, err := s.Has(value)
+	has, err := s.HasContext(ctx, value)
 	if !has && (err == nil) {
 		// set is the name of the column
-		_, err = s.host.db.Exec("INSERT INTO "+s.table+" ("+setColName+") VALUES (?)", value)
+		_, err = s.host.db.ExecContext(ctx, "INSERT INTO "+s.host.q(s.table)+" ("+s.host.q(setColName)+") VALUES ("+s.host.ph(1)+")", value)
 	}
 	return err
 }
 
 // Check if a given value is in the set
 func (s *Set) Has(value string) (bool, error) {
-	rows, err := s.host.db.Query("SELECT " + setColName + " FROM " + s.table + " WHERE " + setColName + " = '" + value + "'")
+	return s.HasContext(context.Background(), value)
+}
+
+// HasContext is Has, with a context.
+func (s *Set) HasContext(ctx context.Context, value string) (bool, error) {
+	col := s.host.q(setColName)
+	rows, err := s.host.db.QueryContext(ctx, "SELECT "+col+" FROM "+s.host.q(s.table)+" WHERE "+col+" = "+s.host.ph(1), value)
 	if err != nil {
-		panic(err.Error())
+		return false, err
 	}
 	defer rows.Close()
 	var scanValue string
 	// Get the value. Should not loop more than once.
 	counter := 0
 	for rows.Next() {
-		err = rows.Scan(&scanValue)
-		if err != nil {
-			panic(err.Error())
+		if err := rows.Scan(&scanValue); err != nil {
+			return false, err
 		}
 		counter++
 	}
 	if err := rows.Err(); err != nil {
-		panic(err.Error())
+		return false, err
 	}
 	if counter > 1 {
-		panic("Duplicate members in set! " + value)
+		return false, errors.New("duplicate members in set: " + value)
 	}
 	return counter > 0, nil
 }
 
 // Get all elements of the set
 func (s *Set) GetAll() ([]string, error) {
-	rows, err := s.host.db.Query("SELECT " + setColName + " FROM " + s.table)
+	return s.GetAllContext(context.Background())
+}
+
+// GetAllContext is GetAll, with a context.
+func (s *Set) GetAllContext(ctx context.Context) ([]string, error) {
+	rows, err := s.host.db.QueryContext(ctx, "SELECT "+s.host.q(setColName)+" FROM "+s.host.q(s.table))
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	defer rows.Close()
 	var (
@@ -317,168 +575,334 @@ func (s *Set) GetAll() ([]string, error) {
 		value  string
 	)
 	for rows.Next() {
-		err = rows.Scan(&value)
-		values = append(values, value)
-		if err != nil {
-			panic(err.Error())
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
 		}
+		values = append(values, value)
 	}
 	if err := rows.Err(); err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	return values, nil
 }
 
 // Remove an element from the set
 func (s *Set) Del(value string) error {
+	return s.DelContext(context.Background(), value)
+}
+
+// DelContext is Del, with a context.
+func (s *Set) DelContext(ctx context.Context, value string) error {
 	// Remove a value from the table
-	_, err := s.host.db.Exec("DELETE FROM " + s.table + " WHERE " + setColName + " = " + value)
+	_, err := s.host.db.ExecContext(ctx, "DELETE FROM "+s.host.q(s.table)+" WHERE "+s.host.q(setColName)+" = "+s.host.ph(1), value)
 	return err
 }
 
 // Remove this set
 func (s *Set) Remove() error {
+	return s.RemoveContext(context.Background())
+}
+
+// RemoveContext is Remove, with a context.
+func (s *Set) RemoveContext(ctx context.Context) error {
 	// Remove the table
-	_, err := s.host.db.Exec("DROP TABLE " + s.table)
+	_, err := s.host.db.ExecContext(ctx, "DROP TABLE "+s.host.q(s.table))
 	return err
 }
 
 // Clear the list contents
 func (s *Set) Clear() error {
+	return s.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, with a context.
+func (s *Set) ClearContext(ctx context.Context) error {
+	// Clear the table
+	_, err := s.host.db.ExecContext(ctx, s.host.dialect.truncate(s.table))
+	return err
+}
+
+/* --- HashMap functions --- */
+
+// Create a new hashmap. A hashmap maps an owner (for instance a user id) and
+// a key (for instance "password") to a value.
+func NewHashMap(host *Host, name string) *HashMap {
+	h := &HashMap{host, name}
+	stmt := "CREATE TABLE IF NOT EXISTS " + host.q(name) + " (" + host.q("owner") + " VARCHAR(" + strconv.Itoa(defaultStringLength) + "), " + host.q("key") + " VARCHAR(" + strconv.Itoa(defaultStringLength) + "), " + host.q("value") + " TEXT, PRIMARY KEY(" + host.q("owner") + ", " + host.q("key") + "))"
+	if _, err := h.host.db.Exec(stmt); err != nil {
+		// This is more likely to happen at the start of the program, hence the panic.
+		panic("Could not create table " + name + ": " + err.Error())
+	}
+	if Verbose {
+		log.Println("Created table " + name + " in database " + host.dbname)
+	}
+	return h
+}
+
+// Set a value in a hashmap given the owner (for instance a user id) and the key (for instance "password")
+func (h *HashMap) Set(owner, key, value string) error {
+	return h.SetContext(context.Background(), owner, key, value)
+}
+
+// SetContext is Set, with a context.
+func (h *HashMap) SetContext(ctx context.Context, owner, key, value string) error {
+	stmt := h.host.dialect.upsert(h.table, []string{"owner", "key", "value"}, []string{"owner", "key"}, []string{"value"})
+	_, err := h.host.db.ExecContext(ctx, stmt, owner, key, value)
+	return err
+}
+
+// Get a value from a hashmap given the owner (for instance a user id) and the key (for instance "password")
+func (h *HashMap) Get(owner, key string) (string, error) {
+	return h.GetContext(context.Background(), owner, key)
+}
+
+// GetContext is Get, with a context.
+func (h *HashMap) GetContext(ctx context.Context, owner, key string) (string, error) {
+	rows, err := h.host.db.QueryContext(ctx, "SELECT "+h.host.q("value")+" FROM "+h.host.q(h.table)+" WHERE "+h.host.q("owner")+" = "+h.host.ph(1)+" AND "+h.host.q("key")+" = "+h.host.ph(2), owner, key)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var value string
+	for rows.Next() {
+		if err := rows.Scan(&value); err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// Check if a given owner + key is in the hashmap
+func (h *HashMap) Has(owner, key string) (bool, error) {
+	return h.HasContext(context.Background(), owner, key)
+}
+
+// HasContext is Has, with a context.
+func (h *HashMap) HasContext(ctx context.Context, owner, key string) (bool, error) {
+	rows, err := h.host.db.QueryContext(ctx, "SELECT "+h.host.q("value")+" FROM "+h.host.q(h.table)+" WHERE "+h.host.q("owner")+" = "+h.host.ph(1)+" AND "+h.host.q("key")+" = "+h.host.ph(2), owner, key)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	counter := 0
+	for rows.Next() {
+		counter++
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return counter > 0, nil
+}
+
+// Check if a given owner exists as a hashmap entry at all
+func (h *HashMap) Exists(owner string) (bool, error) {
+	return h.ExistsContext(context.Background(), owner)
+}
+
+// ExistsContext is Exists, with a context.
+func (h *HashMap) ExistsContext(ctx context.Context, owner string) (bool, error) {
+	rows, err := h.host.db.QueryContext(ctx, "SELECT "+h.host.q("owner")+" FROM "+h.host.q(h.table)+" WHERE "+h.host.q("owner")+" = "+h.host.ph(1), owner)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	counter := 0
+	for rows.Next() {
+		counter++
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return counter > 0, nil
+}
+
+// Get all owners in the hashmap
+func (h *HashMap) GetAll() ([]string, error) {
+	return h.GetAllContext(context.Background())
+}
+
+// GetAllContext is GetAll, with a context.
+func (h *HashMap) GetAllContext(ctx context.Context) ([]string, error) {
+	rows, err := h.host.db.QueryContext(ctx, "SELECT DISTINCT "+h.host.q("owner")+" FROM "+h.host.q(h.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		values []string
+		value  string
+	)
+	for rows.Next() {
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Remove a key for an entry in a hashmap (for instance the email field for a user)
+func (h *HashMap) DelKey(owner, key string) error {
+	return h.DelKeyContext(context.Background(), owner, key)
+}
+
+// DelKeyContext is DelKey, with a context.
+func (h *HashMap) DelKeyContext(ctx context.Context, owner, key string) error {
+	_, err := h.host.db.ExecContext(ctx, "DELETE FROM "+h.host.q(h.table)+" WHERE "+h.host.q("owner")+" = "+h.host.ph(1)+" AND "+h.host.q("key")+" = "+h.host.ph(2), owner, key)
+	return err
+}
+
+// Remove an owner (for instance a user) and all of its keys
+func (h *HashMap) Del(owner string) error {
+	return h.DelContext(context.Background(), owner)
+}
+
+// DelContext is Del, with a context.
+func (h *HashMap) DelContext(ctx context.Context, owner string) error {
+	_, err := h.host.db.ExecContext(ctx, "DELETE FROM "+h.host.q(h.table)+" WHERE "+h.host.q("owner")+" = "+h.host.ph(1), owner)
+	return err
+}
+
+// Remove this hashmap
+func (h *HashMap) Remove() error {
+	return h.RemoveContext(context.Background())
+}
+
+// RemoveContext is Remove, with a context.
+func (h *HashMap) RemoveContext(ctx context.Context) error {
+	// Remove the table
+	_, err := h.host.db.ExecContext(ctx, "DROP TABLE "+h.host.q(h.table))
+	return err
+}
+
+// Clear the hashmap contents
+func (h *HashMap) Clear() error {
+	return h.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, with a context.
+func (h *HashMap) ClearContext(ctx context.Context) error {
 	// Clear the table
-	_, err := s.host.db.Exec("TRUNCATE TABLE " + s.table)
+	_, err := h.host.db.ExecContext(ctx, h.host.dialect.truncate(h.table))
+	return err
+}
+
+/* --- KeyValue functions --- */
+
+// Create a new key/value
+func NewKeyValue(host *Host, name string) *KeyValue {
+	kv := &KeyValue{host, name}
+	stmt := "CREATE TABLE IF NOT EXISTS " + host.q(name) + " (" + host.q("k") + " VARCHAR(" + strconv.Itoa(defaultStringLength) + ") PRIMARY KEY, " + host.q("v") + " TEXT)"
+	if _, err := kv.host.db.Exec(stmt); err != nil {
+		// This is more likely to happen at the start of the program, hence the panic.
+		panic("Could not create table " + name + ": " + err.Error())
+	}
+	if Verbose {
+		log.Println("Created table " + name + " in database " + host.dbname)
+	}
+	return kv
+}
+
+// Set a key and value
+func (kv *KeyValue) Set(key, value string) error {
+	return kv.SetContext(context.Background(), key, value)
+}
+
+// SetContext is Set, with a context.
+func (kv *KeyValue) SetContext(ctx context.Context, key, value string) error {
+	stmt := kv.host.dialect.upsert(kv.table, []string{"k", "v"}, []string{"k"}, []string{"v"})
+	_, err := kv.host.db.ExecContext(ctx, stmt, key, value)
+	return err
+}
+
+// Get a value given a key
+func (kv *KeyValue) Get(key string) (string, error) {
+	return kv.GetContext(context.Background(), key)
+}
+
+// GetContext is Get, with a context.
+func (kv *KeyValue) GetContext(ctx context.Context, key string) (string, error) {
+	rows, err := kv.host.db.QueryContext(ctx, "SELECT "+kv.host.q("v")+" FROM "+kv.host.q(kv.table)+" WHERE "+kv.host.q("k")+" = "+kv.host.ph(1), key)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var value string
+	for rows.Next() {
+		if err := rows.Scan(&value); err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// Remove a key
+func (kv *KeyValue) Del(key string) error {
+	return kv.DelContext(context.Background(), key)
+}
+
+// DelContext is Del, with a context.
+func (kv *KeyValue) DelContext(ctx context.Context, key string) error {
+	_, err := kv.host.db.ExecContext(ctx, "DELETE FROM "+kv.host.q(kv.table)+" WHERE "+kv.host.q("k")+" = "+kv.host.ph(1), key)
+	return err
+}
+
+// Increase the value of a key and return the new value.
+// If the key does not already exist, it is created with the value "1".
+func (kv *KeyValue) Inc(key string) (string, error) {
+	return kv.IncContext(context.Background(), key)
+}
+
+// IncContext is Inc, with a context.
+func (kv *KeyValue) IncContext(ctx context.Context, key string) (string, error) {
+	current, err := kv.GetContext(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	if current != "" {
+		n, err = strconv.Atoi(current)
+		if err != nil {
+			return "", err
+		}
+	}
+	n++
+	next := strconv.Itoa(n)
+	if err := kv.SetContext(ctx, key, next); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// Remove this key/value
+func (kv *KeyValue) Remove() error {
+	return kv.RemoveContext(context.Background())
+}
+
+// RemoveContext is Remove, with a context.
+func (kv *KeyValue) RemoveContext(ctx context.Context) error {
+	// Remove the table
+	_, err := kv.host.db.ExecContext(ctx, "DROP TABLE "+kv.host.q(kv.table))
 	return err
 }
 
-///* --- HashMap functions --- */
-//
-//// Create a new hashmap
-//func NewHashMap(host *sql.DB, table string) *HashMap {
-//	return &HashMap{host, table, defaultDatabaseName}
-//}
-//
-//// Select a different database
-//func (rh *HashMap) SelectDatabase(dbname string) {
-//	rh.dbname = dbname
-//}
-//
-//// Set a value in a hashmap given the element id (for instance a user id) and the key (for instance "password")
-//func (rh *HashMap) Set(elementid, key, value string) error {
-//	db := rh.host.Get(rh.dbname)
-//	_, err := db.Do("HSET", rh.table+":"+elementid, key, value)
-//	return err
-//}
-//
-//// Get a value from a hashmap given the element id (for instance a user id) and the key (for instance "password")
-//func (rh *HashMap) Get(elementid, key string) (string, error) {
-//	db := rh.host.Get(rh.dbname)
-//	result, err := db.String(db.Do("HGET", rh.table+":"+elementid, key))
-//	if err != nil {
-//		return "", err
-//	}
-//	return result, nil
-//}
-//
-//// Check if a given elementid + key is in the hash map
-//func (rh *HashMap) Has(elementid, key string) (bool, error) {
-//	db := rh.host.Get(rh.dbname)
-//	retval, err := db.Do("HEXISTS", rh.table+":"+elementid, key)
-//	if err != nil {
-//		panic(err)
-//	}
-//	return db.Bool(retval, err)
-//}
-//
-//// Check if a given elementid exists as a hash map at all
-//func (rh *HashMap) Exists(elementid string) (bool, error) {
-//	// TODO: key is not meant to be a wildcard, check for "*"
-//	return hasKey(rh.host, rh.table+":"+elementid, rh.dbname)
-//}
-//
-//// Get all elementid's for all hash elements
-//func (rh *HashMap) GetAll() ([]string, error) {
-//	db := rh.host.Get(rh.dbname)
-//	result, err := db.Values(db.Do("KEYS", rh.table+":*"))
-//	strs := make([]string, len(result))
-//	idlen := len(rh.table)
-//	for i := 0; i < len(result); i++ {
-//		strs[i] = getString(result, i)[idlen+1:]
-//	}
-//	return strs, err
-//}
-//
-//// Remove a key for an entry in a hashmap (for instance the email field for a user)
-//func (rh *HashMap) DelKey(elementid, key string) error {
-//	db := rh.host.Get(rh.dbname)
-//	_, err := db.Do("HDEL", rh.table+":"+elementid, key)
-//	return err
-//}
-//
-//// Remove an element (for instance a user)
-//func (rh *HashMap) Del(elementid string) error {
-//	db := rh.host.Get(rh.dbname)
-//	_, err := db.Do("DEL", rh.table+":"+elementid)
-//	return err
-//}
-//
-//// Remove this hashmap
-//func (rh *HashMap) Remove() error {
-//	db := rh.host.Get(rh.dbname)
-//	_, err := db.Do("DEL", rh.table)
-//	return err
-//}
-//
-///* --- KeyValue functions --- */
-//
-//// Create a new key/value
-//func NewKeyValue(host *sql.DB, table string) *KeyValue {
-//	return &KeyValue{host, table, defaultDatabaseName}
-//}
-//
-//// Select a different database
-//func (rkv *KeyValue) SelectDatabase(dbname string) {
-//	rkv.dbname = dbname
-//}
-//
-//// Set a key and value
-//func (rkv *KeyValue) Set(key, value string) error {
-//	db := rkv.host.Get(rkv.dbname)
-//	_, err := db.Do("SET", rkv.table+":"+key, value)
-//	return err
-//}
-//
-//// Get a value given a key
-//func (rkv *KeyValue) Get(key string) (string, error) {
-//	db := rkv.host.Get(rkv.dbname)
-//	result, err := db.String(db.Do("GET", rkv.table+":"+key))
-//	if err != nil {
-//		return "", err
-//	}
-//	return result, nil
-//}
-//
-//// Remove a key
-//func (rkv *KeyValue) Del(key string) error {
-//	db := rkv.host.Get(rkv.dbname)
-//	_, err := db.Do("DEL", rkv.table+":"+key)
-//	return err
-//}
-//
-//// Remove this key/value
-//func (rkv *KeyValue) Remove() error {
-//	db := rkv.host.Get(rkv.dbname)
-//	_, err := db.Do("DEL", rkv.table)
-//	return err
-//}
-//
-//// --- Generic db functions ---
-//
-//// Check if a key exists. The key can be a wildcard (ie. "user*").
-//func hasKey(host *sql.DB, wildcard string, dbname string) (bool, error) {
-//	db := host.Get(dbname)
-//	result, err := db.Values(db.Do("KEYS", wildcard))
-//	if err != nil {
-//		return false, err
-//	}
-//	return len(result) > 0, nil
-//}
+// Clear the key/value contents
+func (kv *KeyValue) Clear() error {
+	return kv.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, with a context.
+func (kv *KeyValue) ClearContext(ctx context.Context) error {
+	// Clear the table
+	_, err := kv.host.db.ExecContext(ctx, kv.host.dialect.truncate(kv.table))
+	return err
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dialect captures the handful of SQL differences between the database
+// backends this package supports, so that List, Set, HashMap and KeyValue
+// only need to be implemented once and routed through whichever dialect
+// the Host was created with.
+type dialect interface {
+	// placeholder returns the parameter placeholder for the n'th (1-indexed)
+	// argument in a query, e.g. "?" for MySQL/SQLite, "$1" for PostgreSQL.
+	placeholder(n int) string
+
+	// quote quotes a table or column identifier.
+	quote(ident string) string
+
+	// autoIncrement returns the column definition for an auto-incrementing
+	// integer primary key, for use in CREATE TABLE statements.
+	autoIncrement() string
+
+	// createDatabase returns the statement that creates the named database,
+	// or "" if this dialect has no separate database to create up front
+	// (SQLite, where the database is just the file given in the DSN).
+	createDatabase(dbname string) string
+
+	// useDatabase returns the statement that selects the given database for
+	// the current connection, or "" if the dialect has no such statement
+	// (PostgreSQL and SQLite both select the database via the DSN instead).
+	useDatabase(dbname string) string
+
+	// upsert returns a full "INSERT ... VALUES (...)" statement that updates
+	// updateCols when a row already exists for the given conflictCols. cols
+	// lists every inserted column, in the order values must be passed to Exec.
+	upsert(table string, cols, conflictCols, updateCols []string) string
+
+	// truncate returns the statement that removes every row from a table.
+	truncate(table string) string
+}
+
+// dialectFor returns the dialect implementation for a known database/sql
+// driver name.
+func dialectFor(driverName string) (dialect, error) {
+	switch driverName {
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "postgres":
+		return postgresDialect{}, nil
+	case "sqlite3":
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("simplegres: unknown driver %q", driverName)
+	}
+}
+
+/* --- MySQL --- */
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) placeholder(int) string { return "?" }
+
+func (mysqlDialect) quote(ident string) string { return "`" + ident + "`" }
+
+func (mysqlDialect) autoIncrement() string { return "INT PRIMARY KEY AUTO_INCREMENT" }
+
+func (d mysqlDialect) createDatabase(dbname string) string {
+	return "CREATE DATABASE IF NOT EXISTS " + d.quote(dbname) + " CHARACTER SET = utf8"
+}
+
+func (d mysqlDialect) useDatabase(dbname string) string {
+	return "USE " + d.quote(dbname)
+}
+
+func (d mysqlDialect) upsert(table string, cols, conflictCols, updateCols []string) string {
+	placeholders := make([]string, len(cols))
+	for i := range cols {
+		placeholders[i] = d.placeholder(i + 1)
+	}
+	updates := make([]string, len(updateCols))
+	for i, col := range updateCols {
+		updates[i] = d.quote(col) + " = VALUES(" + d.quote(col) + ")"
+	}
+	return "INSERT INTO " + d.quote(table) + " (" + quoteJoin(d, cols) + ") VALUES (" + strings.Join(placeholders, ", ") + ") ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+func (mysqlDialect) truncate(table string) string {
+	return "TRUNCATE TABLE " + mysqlDialect{}.quote(table)
+}
+
+/* --- PostgreSQL --- */
+
+type postgresDialect struct{}
+
+func (postgresDialect) placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDialect) quote(ident string) string { return `"` + ident + `"` }
+
+func (postgresDialect) autoIncrement() string { return "SERIAL PRIMARY KEY" }
+
+func (d postgresDialect) createDatabase(dbname string) string {
+	return "CREATE DATABASE " + d.quote(dbname)
+}
+
+func (postgresDialect) useDatabase(string) string { return "" }
+
+func (d postgresDialect) upsert(table string, cols, conflictCols, updateCols []string) string {
+	placeholders := make([]string, len(cols))
+	for i := range cols {
+		placeholders[i] = d.placeholder(i + 1)
+	}
+	updates := make([]string, len(updateCols))
+	for i, col := range updateCols {
+		updates[i] = d.quote(col) + " = EXCLUDED." + d.quote(col)
+	}
+	return "INSERT INTO " + d.quote(table) + " (" + quoteJoin(d, cols) + ") VALUES (" + strings.Join(placeholders, ", ") + ") ON CONFLICT (" + quoteJoin(d, conflictCols) + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+func (postgresDialect) truncate(table string) string {
+	return "TRUNCATE TABLE " + postgresDialect{}.quote(table)
+}
+
+/* --- SQLite --- */
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) placeholder(int) string { return "?" }
+
+func (sqliteDialect) quote(ident string) string { return `"` + ident + `"` }
+
+func (sqliteDialect) autoIncrement() string { return "INTEGER PRIMARY KEY AUTOINCREMENT" }
+
+func (sqliteDialect) createDatabase(string) string { return "" }
+
+func (sqliteDialect) useDatabase(string) string { return "" }
+
+func (d sqliteDialect) upsert(table string, cols, conflictCols, updateCols []string) string {
+	placeholders := make([]string, len(cols))
+	for i := range cols {
+		placeholders[i] = d.placeholder(i + 1)
+	}
+	updates := make([]string, len(updateCols))
+	for i, col := range updateCols {
+		updates[i] = d.quote(col) + " = excluded." + d.quote(col)
+	}
+	return "INSERT INTO " + d.quote(table) + " (" + quoteJoin(d, cols) + ") VALUES (" + strings.Join(placeholders, ", ") + ") ON CONFLICT (" + quoteJoin(d, conflictCols) + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+func (sqliteDialect) truncate(table string) string {
+	return "DELETE FROM " + sqliteDialect{}.quote(table)
+}
+
+// quoteJoin quotes every identifier with d and joins them with ", ".
+func quoteJoin(d dialect, idents []string) string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = d.quote(ident)
+	}
+	return strings.Join(quoted, ", ")
+}